@@ -21,7 +21,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"image"
 	"image/jpeg"
+	"image/png"
 	"io"
 	"net/http"
 	"net/url"
@@ -30,6 +32,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
 )
 
 const sizeMax = int64(1_000_000)
@@ -62,12 +67,25 @@ type bluePost struct {
 		Facets  []blueFacet `json:"facets,omitempty"`
 		Created string      `json:"createdAt"`
 		Embed   *blueEmbed  `json:"embed,omitempty"`
+		Reply   *blueReply  `json:"reply,omitempty"`
 	} `json:"record"`
 	Collection string `json:"collection"`
 }
+
 type blueEmbed struct {
-	Type   string      `json:"$type,omitempty"`
-	Images []blueMedia `json:"images,omitempty"`
+	Type        string           `json:"$type,omitempty"`
+	Images      []blueMedia      `json:"images,omitempty"`
+	Video       *blueBlob        `json:"video,omitempty"`
+	AspectRatio *blueAspectRatio `json:"aspectRatio,omitempty"`
+	Captions    []blueCaption    `json:"captions,omitempty"`
+}
+type blueAspectRatio struct {
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+type blueCaption struct {
+	Lang string    `json:"lang"`
+	File *blueBlob `json:"file"`
 }
 
 type blueFacet struct {
@@ -82,14 +100,19 @@ type blueMedia struct {
 	Image *blueBlob `json:"image"`
 }
 type blueClient struct {
-	_      [0]func()
-	id     string
-	pw     string
-	user   string
-	last   time.Time
-	token  string
-	server string
-	poster *postAccount
+	_        [0]func()
+	id       string
+	pw       string
+	user     string
+	last     time.Time
+	token    string
+	server   string
+	poster   *postAccount
+	resolver mentionResolver
+}
+
+type mentionResolver interface {
+	findUser(x context.Context, n string) string
 }
 type blueFacetData struct {
 	Did  string `json:"did,omitempty"`
@@ -103,6 +126,7 @@ type blueAuthResponse struct {
 	Token string `json:"accessJwt"`
 }
 type bluePostResponse struct {
+	URI   string `json:"uri"`
 	ID    string `json:"cid"`
 	Error string `json:"error"`
 }
@@ -121,6 +145,21 @@ func resizeMedia(m *postMedia) (string, bool, error) {
 	if m.Size < sizeMax {
 		return m.File, false, nil
 	}
+	switch m.Type {
+	case "image/jpeg":
+		return resizeJPEG(m)
+	case "image/png":
+		return resizePNG(m)
+	case "image/webp":
+		return resizeWebP(m)
+	case "image/gif":
+		return "", false, errors.New(`media file "` + m.File + `" (` + strconv.FormatInt(m.Size, 10) + `b) is an oversized animated GIF, convert it to video instead`)
+	default:
+		return m.File, false, nil
+	}
+}
+
+func resizeJPEG(m *postMedia) (string, bool, error) {
 	f, err := os.Open(m.File)
 	if err != nil {
 		return "", false, errors.New(`media open "` + m.File + `" failed: ` + err.Error())
@@ -160,6 +199,68 @@ func resizeMedia(m *postMedia) (string, bool, error) {
 	o.Close()
 	return p, true, err
 }
+
+func resizePNG(m *postMedia) (string, bool, error) {
+	f, err := os.Open(m.File)
+	if err != nil {
+		return "", false, errors.New(`media open "` + m.File + `" failed: ` + err.Error())
+	}
+	i, err := png.Decode(f)
+	if f.Close(); err != nil {
+		return "", false, errors.New(`media read "` + m.File + `" failed: ` + err.Error())
+	}
+	return encodePNGUnderLimit(m, i)
+}
+
+func resizeWebP(m *postMedia) (string, bool, error) {
+	f, err := os.Open(m.File)
+	if err != nil {
+		return "", false, errors.New(`media open "` + m.File + `" failed: ` + err.Error())
+	}
+	i, err := webp.Decode(f)
+	if f.Close(); err != nil {
+		return "", false, errors.New(`media read "` + m.File + `" failed: ` + err.Error())
+	}
+	m.Type = "image/png"
+	return encodePNGUnderLimit(m, i)
+}
+
+func encodePNGUnderLimit(m *postMedia, i image.Image) (string, bool, error) {
+	o, err := os.CreateTemp("", "crosspost-media-convert-*")
+	if err != nil {
+		return "", false, errors.New(`media temp creation failed: ` + err.Error())
+	}
+	enc := png.Encoder{CompressionLevel: png.BestCompression}
+	b := i.Bounds()
+	for w, h := b.Dx(), b.Dy(); ; {
+		if err = o.Truncate(0); err != nil {
+			break
+		}
+		if _, err = o.Seek(0, io.SeekStart); err != nil {
+			break
+		}
+		if err = enc.Encode(o, i); err != nil {
+			break
+		}
+		var v os.FileInfo
+		if v, err = o.Stat(); err != nil {
+			break
+		}
+		if v.Size() < sizeMax {
+			break
+		}
+		if w, h = int(float64(w)*0.75), int(float64(h)*0.75); w < 64 || h < 64 {
+			err = errors.New(`media file "` + m.File + `" (` + strconv.FormatInt(m.Size, 10) + `b) cannot be resized smaller`)
+			break
+		}
+		r := image.NewRGBA(image.Rect(0, 0, w, h))
+		draw.CatmullRom.Scale(r, r.Bounds(), i, b, draw.Over, nil)
+		i = r
+	}
+	p := o.Name()
+	o.Close()
+	return p, true, err
+}
 func (c *blueClient) authenticate(x context.Context) error {
 	if !c.last.IsZero() && time.Now().Sub(c.last) < loginDelay {
 		return nil
@@ -177,45 +278,131 @@ func (c *blueClient) authenticate(x context.Context) error {
 	c.id, c.token, c.last = r.ID, r.Token, time.Now()
 	return nil
 }
-func (c *blueClient) post(x context.Context, d *postData) error {
+
+func (c *blueClient) post(x context.Context, d *postData) (string, string, error) {
 	if err := c.authenticate(x); err != nil {
-		return err
+		return "", "", err
+	}
+	return c.postThread(x, buildThreadSegments(d.Content, d.Link), d.Media, d.Language)
+}
+
+func (c *blueClient) postThread(x context.Context, segments []string, media []postMedia, lang string) (string, string, error) {
+	var root, parent blueStrongRef
+	for i, seg := range segments {
+		var m []postMedia
+		if i == 0 {
+			m = media
+		}
+		p, err := c.buildRecord(x, seg, m, lang)
+		if err != nil {
+			return root.URI, root.CID, err
+		}
+		p.ID, p.Collection = c.id, "app.bsky.feed.post"
+		if i > 0 {
+			p.Record.Reply = &blueReply{Root: root, Parent: parent}
+		}
+		c.poster.parent.log.Debug(`[poster/%s/bluesky]: Posting Skeet %d/%d..`, c.poster.name, i+1, len(segments))
+		var r bluePostResponse
+		if err = c.api(x, http.MethodPost, "com.atproto.repo.createRecord", "", p, &r); err != nil {
+			return root.URI, root.CID, err
+		}
+		if len(r.Error) > 0 {
+			return root.URI, root.CID, errors.New(r.Error)
+		}
+		parent = blueStrongRef{URI: r.URI, CID: r.ID}
+		if i == 0 {
+			root = parent
+		}
 	}
-	c.poster.parent.log.Debug(`[poster/%s/bluesky]: Received post..`, c.poster.name)
-	m := make([]blueMedia, 0, len(d.Media))
-	if len(d.Media) > 0 {
-		c.poster.parent.log.Debug(`[poster/%s/bluesky]: Post has media, processing %d attachments..`, c.poster.name, len(d.Media))
-		for i := range d.Media {
-			if strings.HasPrefix(d.Media[i].Type, "video/") {
-				c.poster.parent.log.Debug(`[poster/%s/bluesky]: Skipping unsupported video attachment..`, c.poster.name)
+	c.poster.parent.log.Info(`[poster/%s/bluesky]: Posted Skeet "%s" (%d segment(s))!`, c.poster.name, root.URI, len(segments))
+	return root.URI, root.CID, nil
+}
+
+func (c *blueClient) edit(x context.Context, uri string, d *postData) (string, string, error) {
+	if err := c.authenticate(x); err != nil {
+		return "", "", err
+	}
+	if segs := buildThreadSegments(d.Content, d.Link); len(segs) > 1 {
+		return "", "", errors.New(`edited content (` + strconv.Itoa(graphemeLen(withLink(d.Content, d.Link))) + ` graphemes) no longer fits in a single record; editing cannot re-thread a post, shorten the content instead`)
+	}
+	p, err := c.buildRecord(x, withLink(d.Content, d.Link), d.Media, d.Language)
+	if err != nil {
+		return "", "", err
+	}
+	var b struct {
+		Repo       string      `json:"repo"`
+		Collection string      `json:"collection"`
+		Rkey       string      `json:"rkey"`
+		Record     interface{} `json:"record"`
+	}
+	b.Repo, b.Collection, b.Record = c.id, "app.bsky.feed.post", p.Record
+	if n := strings.LastIndexByte(uri, '/'); n >= 0 {
+		b.Rkey = uri[n+1:]
+	}
+	c.poster.parent.log.Debug(`[poster/%s/bluesky]: Editing Skeet "%s"..`, c.poster.name, uri)
+	var r bluePostResponse
+	if err = c.api(x, http.MethodPost, "com.atproto.repo.putRecord", "", b, &r); err != nil {
+		return "", "", err
+	}
+	if len(r.Error) > 0 {
+		return "", "", errors.New(r.Error)
+	}
+	c.poster.parent.log.Info(`[poster/%s/bluesky]: Edited Skeet "%s"!`, c.poster.name, uri)
+	return uri, r.ID, nil
+}
+
+func (c *blueClient) buildRecord(x context.Context, text string, media []postMedia, lang string) (bluePost, error) {
+	var (
+		m     []blueMedia
+		video *blueBlob
+	)
+	if len(media) > 0 {
+		c.poster.parent.log.Debug(`[poster/%s/bluesky]: Post has media, processing %d attachments..`, c.poster.name, len(media))
+		var images []postMedia
+		for i := range media {
+			if !strings.HasPrefix(media[i].Type, "video/") {
+				images = append(images, media[i])
+				continue
+			}
+			if video != nil {
+				c.poster.parent.log.Debug(`[poster/%s/bluesky]: Skipping extra video attachment, only one is allowed per Skeet..`, c.poster.name)
 				continue
 			}
-			r, err := c.postMedia(x, &d.Media[i])
+			v, err := c.postVideo(x, &media[i])
 			if err != nil {
-				return errors.New("media initialize failed: " + err.Error())
+				return bluePost{}, errors.New("video initialize failed: " + err.Error())
 			}
-			m = append(m, blueMedia{Alt: d.Media[i].Text, Image: r})
+			video = v
 		}
+		switch {
+		case video != nil && len(images) > 0:
+			c.poster.parent.log.Debug(`[poster/%s/bluesky]: Dropping %d image attachment(s), a Skeet cannot embed both images and video..`, c.poster.name, len(images))
+		case len(images) > 0:
+			r, err := c.postMedia(x, images)
+			if err != nil {
+				return bluePost{}, errors.New("media initialize failed: " + err.Error())
+			}
+			m = make([]blueMedia, len(images))
+			for i := range images {
+				m[i] = blueMedia{Alt: images[i].Text, Image: r[i]}
+			}
+		}
+	}
+	if len(lang) == 0 {
+		lang = defaultLanguage
 	}
 	var p bluePost
-	p.ID, p.Collection = c.id, "app.bsky.feed.post"
-	p.Record.Langs = []string{"en-US"}
-	p.Record.Type, p.Record.Text = "app.bsky.feed.post", d.Content
-	p.Record.Facets = c.facetTags(d.Content, c.facetURLs(d.Content, c.facetMentions(x, d.Content)))
+	p.Record.Langs = []string{lang}
+	p.Record.Type, p.Record.Text = "app.bsky.feed.post", text
+	p.Record.Facets = c.buildFacets(x, text)
 	p.Record.Created = time.Now().UTC().Format("2006-01-02T15:04:05.999999Z")
-	if len(m) > 0 {
+	switch {
+	case video != nil:
+		p.Record.Embed = &blueEmbed{Type: "app.bsky.embed.video", Video: video}
+	case len(m) > 0:
 		p.Record.Embed = &blueEmbed{Type: "app.bsky.embed.images", Images: m}
 	}
-	c.poster.parent.log.Debug(`[poster/%s/bluesky]: Posting Skeet..`, c.poster.name)
-	var r bluePostResponse
-	if err := c.api(x, http.MethodPost, "com.atproto.repo.createRecord", "", p, &r); err != nil {
-		return err
-	}
-	if len(r.Error) > 0 {
-		return errors.New(r.Error)
-	}
-	c.poster.parent.log.Info(`[poster/%s/bluesky]: Posted Skeet "%s"!`, c.poster.name, r.ID)
-	return nil
+	return p, nil
 }
 func (c *blueClient) findUser(x context.Context, n string) string {
 	var i blueAuthResponse // It's the same struct format
@@ -277,27 +464,52 @@ func (c *blueClient) facetURLs(s string, r []blueFacet) []blueFacet {
 	}
 	return r
 }
-func (c *blueClient) facetMentions(x context.Context, s string) []blueFacet {
-	m := expMentions.FindAllStringIndex(s, -1)
+
+func (c *blueClient) facetMentions(x context.Context, s string, r []blueFacet) []blueFacet {
+	m := expMentions.FindAllStringSubmatchIndex(s, -1)
 	if m == nil {
-		return nil
+		return r
+	}
+	res := c.resolver
+	if res == nil {
+		res = c
 	}
-	r := make([]blueFacet, 0, len(m))
 	for _, v := range m {
-		if len(v) == 0 || v[0] < 0 || v[1] > len(s) || v[0]+1 >= v[1] {
+		if len(v) < 4 || v[0] < 0 || v[3] > len(s) || v[0]+1 >= v[3] {
 			continue
 		}
 		var f blueFacet
 		f.Features = []blueFacetData{blueFacetData{Type: "app.bsky.richtext.facet#mention"}}
-		if f.Features[0].Did = c.findUser(x, s[v[0]+1:v[1]-1]); len(f.Features[0].Did) == 0 {
+		if f.Features[0].Did = res.findUser(x, s[v[0]+1:v[3]]); len(f.Features[0].Did) == 0 {
 			continue
 		}
-		f.Index.End, f.Index.Start = v[1], v[0]
+		f.Index.Start, f.Index.End = v[0], v[3]
 		r = append(r, f)
 	}
 	return r
 }
-func (c *blueClient) postMedia(x context.Context, m *postMedia) (*blueBlob, error) {
+
+func (c *blueClient) postMedia(x context.Context, media []postMedia) ([]*blueBlob, error) {
+	j := make([]*mediaJob, len(media))
+	for i := range media {
+		k := newMediaJob(x, media[i].File, media[i].Type)
+		k.work = func(c2 context.Context) (interface{}, error) {
+			return c.uploadMedia(c2, &media[i])
+		}
+		c.poster.media.submit(k)
+		j[i] = k
+	}
+	r := make([]*blueBlob, len(j))
+	for i := range j {
+		o := <-j[i].done
+		if o.Err != nil {
+			return nil, o.Err
+		}
+		r[i] = o.Value.(*blueBlob)
+	}
+	return r, nil
+}
+func (c *blueClient) uploadMedia(x context.Context, m *postMedia) (*blueBlob, error) {
 	p, d, err := resizeMedia(m)
 	if err != nil {
 		return nil, err
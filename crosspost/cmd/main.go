@@ -21,6 +21,7 @@ import (
 	"os"
 
 	crosspost "github.com/PurpleSec/CrossPost"
+	"golang.org/x/term"
 )
 
 var buildVersion = "unknown"
@@ -35,27 +36,98 @@ Usage:
   -V         Print version string and exit.
   -f <file>  Configuration file path.
   -d         Dump the default configuration and exit.
-
-The only optional values are the "prefix" and "replace" values.
+  -e <file>  Export the config at "-f" (and every account's state store) to an
+             encrypted archive at this path, then exit. Prompts for a
+             passphrase on stdin.
+  -i <file>  Import an encrypted archive from this path, writing its config to
+             "-f" and every state store back to its original path, then exit.
+             Prompts for a passphrase on stdin.
+
+The only optional values are the "prefix", "replace", "workers", "state",
+"edit_mode", "language", "languages", "include_tags", "exclude_tags", "feed"
+and "micropub_token" values. An account needs at least one of "bluesky",
+"twitter" or "feed". The top-level "micropub" and "feed" entries are also
+optional.
 
 Prefix which takes a URL value that will be appended to Tweets (if the char limit
 allows!) with the Mastodon post ID. This can be used as a quasi-link shortener.
 
-Replace will replace the specified string matching phrases with the specified string
-or character (or emoji!). These are case sensitive.
+Workers sets the number of concurrent media download/upload jobs an account may
+run at once. Defaults to 4 when unset or zero.
+
+State takes a file path to a bbolt database used to persist the mapping between
+a Mastodon status and the downstream posts it produced, so that a later edit to
+that status can be propagated. When omitted, no mapping is kept and edits to
+that account's statuses are not propagated to BlueSky or Twitter; an account's
+"feed" entry still has the edited item updated.
+
+Edit_mode controls what happens when a tracked Mastodon status is edited: "replace"
+(the default) updates the downstream posts in place where the platform allows it
+and falls back to a silent delete+repost where it doesn't (Twitter), "append_edit_note"
+does the same but appends an "(edited)" marker to the content, and "ignore" drops
+edits entirely.
+
+Language sets the BCP-47 language tag recorded on a post when the source Mastodon
+status does not specify one and a simple script-based guess cannot be made.
+Defaults to "en-US" when unset.
+
+Languages takes "include" and "exclude" lists of BCP-47 tags and filters which
+statuses get forwarded based on the Mastodon status' language. An empty
+"include" allows every language except those in "exclude"; a non-empty
+"include" allows only those languages (still subject to "exclude"). Matching
+is case-insensitive and ignores the status' region subtag, so "en" matches
+"en-US"; a listed tag with a region (such as "en-US") matches only that tag.
+Leaving "languages" unset forwards every status.
+
+Include_tags and exclude_tags take case-insensitive hashtag lists (a leading
+"#" is optional) and filter which statuses get forwarded based on the
+Mastodon status' hashtags. Exclude is checked first and vetoes a match;
+include is an OR match against the remaining statuses. Leaving both unset
+forwards every status regardless of its hashtags. This lets a single
+Mastodon account fan out selectively, e.g. forwarding only "#security" posts
+to one destination and "#art" toots to another.
+
+Replace takes either the legacy "from": "to" string map (kept for backward
+compatibility) or a list of rule objects: {"pattern", "replacement", "regex",
+"targets"}. Plain rules do a literal, case-sensitive substitution; "regex":
+true compiles "pattern" as a Go regexp (failing config validation if it
+doesn't compile) and substitutes with "ReplaceAllString", so capture groups
+in "replacement" work. "targets" restricts a rule to the named downstream
+platforms ("twitter", "bluesky", "feed"); omitting it applies the rule
+everywhere. This lets, for example, "@user@instance" mentions be stripped
+for Twitter while left intact for BlueSky.
+
+Micropub (top-level, alongside "accounts") enables an inbound Micropub
+(https://micropub.spec.indieweb.org/) endpoint, letting clients like Quill,
+Indigenous or Omnibear post directly into the cross-post pipeline without
+going through Mastodon. It takes a "listen" address, an optional
+"media_endpoint" URL advertised to clients, and an optional "token_endpoint"
+used to verify bearer tokens that don't match any account's "micropub_token".
+An account only accepts Micropub posts once its own "micropub_token" is set.
+
+Feed (top-level, alongside "accounts") enables an embedded RSS/Atom/JSON feed
+server, letting readers follow an account without a Twitter or BlueSky
+account at all. It takes a "listen" address. An account opts in with its own
+"feed" entry: "path" (the URL path it's served at), "title", "link",
+"description", "format" ("rss", "atom" or "json", defaulting to "rss") and
+"max_items" (the size of the in-memory ring buffer, defaulting to 50).
+Nothing is persisted to disk, so a restart starts every feed empty. An
+account may set "feed" on its own, with no "bluesky" or "twitter" entry.
 `
 
 func main() {
 	var (
-		args      = flag.NewFlagSet("CrossPost "+version+"_"+buildVersion, flag.ExitOnError)
-		file      string
-		dump, ver bool
+		args           = flag.NewFlagSet("CrossPost "+version+"_"+buildVersion, flag.ExitOnError)
+		file, exp, imp string
+		dump, ver      bool
 	)
 	args.Usage = func() {
 		os.Stderr.WriteString(usage)
 		os.Exit(2)
 	}
 	args.StringVar(&file, "f", "", "")
+	args.StringVar(&exp, "e", "", "")
+	args.StringVar(&imp, "i", "", "")
 	args.BoolVar(&dump, "d", false, "")
 	args.BoolVar(&ver, "V", false, "")
 
@@ -79,6 +151,32 @@ func main() {
 		os.Exit(0)
 	}
 
+	if len(exp) > 0 {
+		p, err := readPassphrase()
+		if err != nil {
+			os.Stdout.WriteString("Error: " + err.Error() + "!\n")
+			os.Exit(1)
+		}
+		if err := crosspost.Export(file, exp, p); err != nil {
+			os.Stdout.WriteString("Error: " + err.Error() + "!\n")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if len(imp) > 0 {
+		p, err := readPassphrase()
+		if err != nil {
+			os.Stdout.WriteString("Error: " + err.Error() + "!\n")
+			os.Exit(1)
+		}
+		if err := crosspost.Import(imp, file, p); err != nil {
+			os.Stdout.WriteString("Error: " + err.Error() + "!\n")
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	s, err := crosspost.New(file)
 	if err != nil {
 		os.Stdout.WriteString("Error: " + err.Error() + "!\n")
@@ -90,3 +188,10 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func readPassphrase() ([]byte, error) {
+	os.Stderr.WriteString("Passphrase: ")
+	p, err := term.ReadPassword(int(os.Stdin.Fd()))
+	os.Stderr.WriteString("\n")
+	return p, err
+}
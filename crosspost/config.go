@@ -17,11 +17,29 @@
 package crosspost
 
 import (
+	"encoding/json"
 	"errors"
+	"os"
+	"regexp"
 	"strconv"
 	"time"
 )
 
+func loadConfig(s string) (*config, error) {
+	var c config
+	j, err := os.ReadFile(s)
+	if err != nil {
+		return nil, errors.New(`reading config "` + s + `" failed: ` + err.Error())
+	}
+	if err = json.Unmarshal(j, &c); err != nil {
+		return nil, errors.New(`parsing config "` + s + `" failed: ` + err.Error())
+	}
+	if err = c.check(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
 // Defaults is a string representation of a JSON formatted default configuration
 // for a Crosspost instance.
 const Defaults = `{
@@ -70,21 +88,112 @@ const Defaults = `{
 
 const blueDefaultServer = "bsky.social"
 
+const (
+	editModeReplace    = "replace"
+	editModeAppendNote = "append_edit_note"
+	editModeIgnore     = "ignore"
+)
+
 type log struct {
 	File  string `json:"file"`
 	Level int    `json:"level"`
 }
 type config struct {
-	Log      log           `json:"log"`
-	Timeout  time.Duration `json:"timeout"`
-	Accounts []account     `json:"accounts"`
+	Log      log             `json:"log"`
+	Feed     *feedConfig     `json:"feed"`
+	Micropub *micropubConfig `json:"micropub"`
+	Timeout  time.Duration   `json:"timeout"`
+	Accounts []account       `json:"accounts"`
 }
 type account struct {
-	Blue     *accountBlue      `json:"bluesky"`
-	Prefix   string            `json:"prefix"`
-	Replace  map[string]string `json:"replace"`
-	Twitter  *accountTwitter   `json:"twitter"`
-	Mastodon *accountMastodon  `json:"mastodon"`
+	Blue          *accountBlue      `json:"bluesky"`
+	Prefix        string            `json:"prefix"`
+	State         string            `json:"state"`
+	Replace       []replaceRule     `json:"replace"`
+	Twitter       *accountTwitter   `json:"twitter"`
+	Mastodon      *accountMastodon  `json:"mastodon"`
+	EditMode      string            `json:"edit_mode"`
+	Language      string            `json:"language"`
+	Languages     *accountLanguages `json:"languages"`
+	Workers       int               `json:"workers"`
+	MicropubToken string            `json:"micropub_token"`
+	IncludeTags   []string          `json:"include_tags"`
+	ExcludeTags   []string          `json:"exclude_tags"`
+	Feed          *accountFeed      `json:"feed"`
+}
+
+func (a *account) UnmarshalJSON(b []byte) error {
+	type shadow account
+	v := struct {
+		Replace json.RawMessage `json:"replace"`
+		*shadow
+	}{shadow: (*shadow)(a)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	if len(v.Replace) == 0 || string(v.Replace) == "null" {
+		return nil
+	}
+	var rules []replaceRule
+	if err := json.Unmarshal(v.Replace, &rules); err == nil {
+		a.Replace = rules
+		return nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal(v.Replace, &m); err != nil {
+		return errors.New(`"replace" entry is malformed: ` + err.Error())
+	}
+	a.Replace = make([]replaceRule, 0, len(m))
+	for k, r := range m {
+		a.Replace = append(a.Replace, replaceRule{Pattern: k, Replacement: r})
+	}
+	return nil
+}
+
+const (
+	replaceTargetTwitter = "twitter"
+	replaceTargetBlue    = "bluesky"
+	replaceTargetFeed    = "feed"
+)
+
+type replaceRule struct {
+	Pattern     string   `json:"pattern"`
+	Replacement string   `json:"replacement"`
+	Regex       bool     `json:"regex"`
+	Targets     []string `json:"targets"`
+	re          *regexp.Regexp
+}
+
+type accountLanguages struct {
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+}
+
+type micropubConfig struct {
+	Listen        string `json:"listen"`
+	MediaEndpoint string `json:"media_endpoint"`
+	TokenEndpoint string `json:"token_endpoint"`
+}
+
+const defaultFeedMaxItems = 50
+
+const (
+	feedFormatRSS  = "rss"
+	feedFormatAtom = "atom"
+	feedFormatJSON = "json"
+)
+
+type feedConfig struct {
+	Listen string `json:"listen"`
+}
+
+type accountFeed struct {
+	Path        string `json:"path"`
+	Title       string `json:"title"`
+	Link        string `json:"link"`
+	Description string `json:"description"`
+	Format      string `json:"format"`
+	MaxItems    int    `json:"max_items"`
 }
 type accountBlue struct {
 	Server   string `json:"server"`
@@ -112,8 +221,8 @@ func (c *config) check() error {
 		if c.Accounts[i].Mastodon == nil {
 			return errors.New(`account at "` + strconv.Itoa(i) + `" is missing "mastodon"`)
 		}
-		if c.Accounts[i].Blue == nil && c.Accounts[i].Twitter == nil {
-			return errors.New(`account at "` + strconv.Itoa(i) + `" does not have a "bluesky" or "twitter" entry`)
+		if c.Accounts[i].Blue == nil && c.Accounts[i].Twitter == nil && c.Accounts[i].Feed == nil {
+			return errors.New(`account at "` + strconv.Itoa(i) + `" does not have a "bluesky", "twitter" or "feed" entry`)
 		}
 		if len(c.Accounts[i].Mastodon.Server) == 0 {
 			return errors.New(`account at "` + strconv.Itoa(i) + `" has a missing or empty "mastodon"->"server" entry`)
@@ -127,6 +236,40 @@ func (c *config) check() error {
 		if len(c.Accounts[i].Mastodon.AccessToken) == 0 {
 			return errors.New(`account at "` + strconv.Itoa(i) + `" has a missing or empty "mastodon"->"access_token" entry`)
 		}
+		if c.Accounts[i].Workers < 0 {
+			return errors.New(`account at "` + strconv.Itoa(i) + `" has an invalid "workers" entry`)
+		}
+		switch c.Accounts[i].EditMode {
+		case "", editModeReplace, editModeAppendNote, editModeIgnore:
+		default:
+			return errors.New(`account at "` + strconv.Itoa(i) + `" has an invalid "edit_mode" entry`)
+		}
+		if len(c.Accounts[i].Language) == 0 {
+			c.Accounts[i].Language = defaultLanguage
+		}
+		c.Accounts[i].IncludeTags = normalizeTags(c.Accounts[i].IncludeTags)
+		c.Accounts[i].ExcludeTags = normalizeTags(c.Accounts[i].ExcludeTags)
+		for j := range c.Accounts[i].Replace {
+			r := &c.Accounts[i].Replace[j]
+			if len(r.Pattern) == 0 {
+				return errors.New(`account at "` + strconv.Itoa(i) + `" has a "replace" entry with an empty "pattern"`)
+			}
+			for _, t := range r.Targets {
+				switch t {
+				case replaceTargetTwitter, replaceTargetBlue, replaceTargetFeed:
+				default:
+					return errors.New(`account at "` + strconv.Itoa(i) + `" has a "replace" entry with an invalid target "` + t + `"`)
+				}
+			}
+			if !r.Regex {
+				continue
+			}
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return errors.New(`account at "` + strconv.Itoa(i) + `" has an invalid "replace" regex "` + r.Pattern + `": ` + err.Error())
+			}
+			r.re = re
+		}
 		if c.Accounts[i].Blue != nil {
 			if len(c.Accounts[i].Blue.Server) == 0 {
 				c.Accounts[i].Blue.Server = blueDefaultServer
@@ -152,9 +295,36 @@ func (c *config) check() error {
 				return errors.New(`account at "` + strconv.Itoa(i) + `" has a missing or empty "twitter"->"consumer_secret" entry`)
 			}
 		}
+		if c.Accounts[i].Feed != nil {
+			if len(c.Accounts[i].Feed.Path) == 0 {
+				return errors.New(`account at "` + strconv.Itoa(i) + `" has a missing or empty "feed"->"path" entry`)
+			}
+			switch c.Accounts[i].Feed.Format {
+			case "":
+				c.Accounts[i].Feed.Format = feedFormatRSS
+			case feedFormatRSS, feedFormatAtom, feedFormatJSON:
+			default:
+				return errors.New(`account at "` + strconv.Itoa(i) + `" has an invalid "feed"->"format" entry`)
+			}
+			if c.Accounts[i].Feed.MaxItems < 0 {
+				return errors.New(`account at "` + strconv.Itoa(i) + `" has an invalid "feed"->"max_items" entry`)
+			}
+			if c.Accounts[i].Feed.MaxItems == 0 {
+				c.Accounts[i].Feed.MaxItems = defaultFeedMaxItems
+			}
+			if c.Feed == nil {
+				return errors.New(`account at "` + strconv.Itoa(i) + `" has a "feed" entry but no top-level "feed" server is configured`)
+			}
+		}
 	}
 	if c.Timeout == 0 {
 		c.Timeout = time.Second * 5
 	}
+	if c.Feed != nil && len(c.Feed.Listen) == 0 {
+		return errors.New(`"feed" entry is missing a "listen" address`)
+	}
+	if c.Micropub != nil && len(c.Micropub.Listen) == 0 {
+		return errors.New(`"micropub" entry is missing a "listen" address`)
+	}
 	return nil
 }
@@ -18,7 +18,6 @@ package crosspost
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"os"
 	"os/signal"
@@ -38,6 +37,8 @@ type CrossPost struct {
 	log      logx.Log
 	cancel   context.CancelFunc
 	accounts []*postAccount
+	micropub *micropubServer
+	feed     *feedServer
 }
 
 // Run will start the main CrossPost service and all associated threads. This
@@ -54,6 +55,12 @@ func (c *CrossPost) Run() error {
 	signal.Notify(o, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
 	x, c.cancel = context.WithCancel(context.Background())
 	c.log.Info("CrossPost Started, spinning up sender/receiver threads..")
+	if c.micropub != nil {
+		c.micropub.start()
+	}
+	if c.feed != nil {
+		c.feed.start()
+	}
 	for i := range c.accounts {
 		c.log.Debug(`[%s]: Starting stream monitor "%s"..`, c.accounts[i].name, c.accounts[i].name)
 		if err = c.accounts[i].start(x, &g); err != nil {
@@ -76,6 +83,17 @@ cleanup:
 	}
 	c.cancel()
 	g.Wait()
+	for i := range c.accounts {
+		c.accounts[i].media.stop()
+		c.accounts[i].video.stop()
+		c.accounts[i].store.close()
+	}
+	if c.micropub != nil {
+		c.micropub.stop()
+	}
+	if c.feed != nil {
+		c.feed.stop()
+	}
 	close(o)
 	return err
 }
@@ -84,15 +102,8 @@ cleanup:
 // function will preform any setup steps needed to start the CrossPost service.
 // Once complete, use the 'Run' function to actually start the service.
 func New(s string) (*CrossPost, error) {
-	var c config
-	j, err := os.ReadFile(s)
+	c, err := loadConfig(s)
 	if err != nil {
-		return nil, errors.New(`reading config "` + s + `" failed: ` + err.Error())
-	}
-	if err = json.Unmarshal(j, &c); err != nil {
-		return nil, errors.New(`parsing config "` + s + `" failed: ` + err.Error())
-	}
-	if err = c.check(); err != nil {
 		return nil, err
 	}
 	l := logx.Multiple(logx.Console(logx.Level(c.Log.Level)))
@@ -109,5 +120,7 @@ func New(s string) (*CrossPost, error) {
 			return nil, errors.New(`account "` + strconv.Itoa(i) + `" setup failed: ` + err.Error())
 		}
 	}
+	x.micropub = x.newMicropub(c.Micropub)
+	x.feed = x.newFeedServer(c.Feed)
 	return x, nil
 }
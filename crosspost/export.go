@@ -0,0 +1,185 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	archiveMagic    = "CPXA"
+	archiveVersion1 = uint32(1)
+)
+
+const (
+	scryptN      = 1 << 15
+	scryptR      = 8
+	scryptP      = 1
+	scryptKeyLen = 32
+	saltSize     = 16
+)
+
+type archiveStore struct {
+	Path string `json:"path"`
+	Data []byte `json:"data"`
+}
+
+type archivePayload struct {
+	Config config         `json:"config"`
+	Stores []archiveStore `json:"stores"`
+}
+
+// Export reads, validates and encrypts the config at 'configPath' (along
+// with every account's "state" store) into a single archive at 'archivePath',
+// protected by 'passphrase'.
+func Export(configPath, archivePath string, passphrase []byte) error {
+	c, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	p := archivePayload{Config: *c}
+	for i := range c.Accounts {
+		if len(c.Accounts[i].State) == 0 {
+			continue
+		}
+		d, err := os.ReadFile(c.Accounts[i].State)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return errors.New(`reading state "` + c.Accounts[i].State + `" failed: ` + err.Error())
+		}
+		p.Stores = append(p.Stores, archiveStore{Path: c.Accounts[i].State, Data: d})
+	}
+	j, err := json.Marshal(p)
+	if err != nil {
+		return errors.New("marshaling archive failed: " + err.Error())
+	}
+	return writeArchive(archivePath, passphrase, j)
+}
+
+// Import decrypts the archive at 'archivePath' with 'passphrase', writing
+// its config back to 'configPath' and every captured state store back to
+// the path it was exported from.
+func Import(archivePath, configPath string, passphrase []byte) error {
+	j, err := readArchive(archivePath, passphrase)
+	if err != nil {
+		return err
+	}
+	var p archivePayload
+	if err = json.Unmarshal(j, &p); err != nil {
+		return errors.New(`archive "` + archivePath + `" is corrupt: ` + err.Error())
+	}
+	o, err := json.MarshalIndent(p.Config, "", "    ")
+	if err != nil {
+		return errors.New("marshaling config failed: " + err.Error())
+	}
+	if err = os.WriteFile(configPath, o, 0o600); err != nil {
+		return errors.New(`writing config "` + configPath + `" failed: ` + err.Error())
+	}
+	for i := range p.Stores {
+		if err = os.WriteFile(p.Stores[i].Path, p.Stores[i].Data, 0o600); err != nil {
+			return errors.New(`writing state "` + p.Stores[i].Path + `" failed: ` + err.Error())
+		}
+	}
+	return nil
+}
+
+func writeArchive(path string, passphrase, data []byte) error {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return errors.New("generating salt failed: " + err.Error())
+	}
+	g, err := newArchiveGCM(passphrase, salt)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, g.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return errors.New("generating nonce failed: " + err.Error())
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return errors.New(`creating archive "` + path + `" failed: ` + err.Error())
+	}
+	defer f.Close()
+	var v [4]byte
+	binary.BigEndian.PutUint32(v[:], archiveVersion1)
+	for _, b := range [][]byte{[]byte(archiveMagic), v[:], salt, nonce, g.Seal(nil, nonce, data, nil)} {
+		if _, err = f.Write(b); err != nil {
+			return errors.New(`writing archive "` + path + `" failed: ` + err.Error())
+		}
+	}
+	return nil
+}
+
+func readArchive(path string, passphrase []byte) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.New(`reading archive "` + path + `" failed: ` + err.Error())
+	}
+	if len(b) < len(archiveMagic)+4+saltSize {
+		return nil, errors.New(`archive "` + path + `" is too short`)
+	}
+	if string(b[:len(archiveMagic)]) != archiveMagic {
+		return nil, errors.New(`archive "` + path + `" has an invalid magic header`)
+	}
+	b = b[len(archiveMagic):]
+	if v := binary.BigEndian.Uint32(b[:4]); v != archiveVersion1 {
+		return nil, errors.New(`archive "` + path + `" has an unsupported version "` + strconv.FormatUint(uint64(v), 10) + `"`)
+	}
+	b = b[4:]
+	salt, b := b[:saltSize], b[saltSize:]
+	g, err := newArchiveGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < g.NonceSize() {
+		return nil, errors.New(`archive "` + path + `" is too short`)
+	}
+	nonce, b := b[:g.NonceSize()], b[g.NonceSize():]
+	d, err := g.Open(nil, nonce, b, nil)
+	if err != nil {
+		return nil, errors.New("decrypting archive failed, check the passphrase: " + err.Error())
+	}
+	return d, nil
+}
+
+func newArchiveGCM(passphrase, salt []byte) (cipher.AEAD, error) {
+	k, err := scrypt.Key(passphrase, salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, errors.New("deriving key failed: " + err.Error())
+	}
+	b, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, errors.New("creating cipher failed: " + err.Error())
+	}
+	g, err := cipher.NewGCM(b)
+	if err != nil {
+		return nil, errors.New("creating GCM failed: " + err.Error())
+	}
+	return g, nil
+}
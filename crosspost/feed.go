@@ -0,0 +1,137 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/feeds"
+	"github.com/mattn/go-mastodon"
+)
+
+type feedItem struct {
+	id      mastodon.ID
+	content string
+	link    string
+	created time.Time
+}
+
+type feedSink struct {
+	_     [0]func()
+	cfg   *accountFeed
+	mu    sync.Mutex
+	items []feedItem
+}
+
+func newFeedSink(cfg *accountFeed) *feedSink {
+	if cfg == nil {
+		return nil
+	}
+	return &feedSink{cfg: cfg}
+}
+
+func (f *feedSink) add(d *postData) {
+	f.mu.Lock()
+	if len(f.items) >= f.cfg.MaxItems {
+		f.items = append(f.items[:0], f.items[1:]...)
+	}
+	f.items = append(f.items, feedItem{id: d.ID, content: d.Content, link: d.Link, created: time.Now()})
+	f.mu.Unlock()
+}
+
+func (f *feedSink) update(d *postData) {
+	f.mu.Lock()
+	for i := range f.items {
+		if f.items[i].id == d.ID {
+			f.items[i].content, f.items[i].link = d.Content, d.Link
+			break
+		}
+	}
+	f.mu.Unlock()
+}
+
+func (f *feedSink) render() (string, string, error) {
+	o := &feeds.Feed{
+		Title:       f.cfg.Title,
+		Link:        &feeds.Link{Href: f.cfg.Link},
+		Description: f.cfg.Description,
+		Created:     time.Now(),
+	}
+	f.mu.Lock()
+	for i := len(f.items) - 1; i >= 0; i-- {
+		v := f.items[i]
+		o.Add(&feeds.Item{Id: string(v.id), Link: &feeds.Link{Href: v.link}, Description: v.content, Content: v.content, Created: v.created})
+	}
+	f.mu.Unlock()
+	switch f.cfg.Format {
+	case feedFormatAtom:
+		s, err := o.ToAtom()
+		return s, "application/atom+xml; charset=utf-8", err
+	case feedFormatJSON:
+		s, err := o.ToJSON()
+		return s, "application/json; charset=utf-8", err
+	default:
+		s, err := o.ToRss()
+		return s, "application/rss+xml; charset=utf-8", err
+	}
+}
+
+type feedServer struct {
+	_      [0]func()
+	srv    *http.Server
+	parent *CrossPost
+}
+
+func (c *CrossPost) newFeedServer(cfg *feedConfig) *feedServer {
+	if cfg == nil {
+		return nil
+	}
+	f := &feedServer{parent: c}
+	f.srv = &http.Server{Addr: cfg.Listen, Handler: f, ReadHeaderTimeout: time.Second * 10}
+	return f
+}
+func (f *feedServer) start() {
+	f.parent.log.Info(`[feed]: Starting feed server on "%s"..`, f.srv.Addr)
+	go func() {
+		if err := f.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			f.parent.log.Error(`[feed]: Server on "%s" failed: %s!`, f.srv.Addr, err.Error())
+		}
+	}()
+}
+func (f *feedServer) stop() {
+	f.srv.Close()
+}
+func (f *feedServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	for _, p := range f.parent.accounts {
+		if p.feed == nil || p.feed.cfg.Path != r.URL.Path {
+			continue
+		}
+		b, ct, err := p.feed.render()
+		if err != nil {
+			f.parent.log.Error(`[feed/%s]: Rendering feed failed: %s!`, p.name, err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ct)
+		w.Write([]byte(b))
+		return
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
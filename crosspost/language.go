@@ -0,0 +1,98 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"strings"
+	"unicode"
+)
+
+const defaultLanguage = "en-US"
+
+type languageDetector interface {
+	Detect(s string) (string, bool)
+}
+
+type scriptDetector struct{}
+
+var defaultLanguageDetector languageDetector = scriptDetector{}
+
+func normalizeLangs(l []string) []string {
+	if len(l) == 0 {
+		return nil
+	}
+	o := make([]string, 0, len(l))
+	for _, v := range l {
+		if len(v) == 0 {
+			continue
+		}
+		o = append(o, strings.ToLower(v))
+	}
+	return o
+}
+
+func langMatches(lang string, list []string) bool {
+	l := strings.ToLower(lang)
+	p := l
+	if n := strings.IndexByte(l, '-'); n > 0 {
+		p = l[:n]
+	}
+	for _, v := range list {
+		if v == l || v == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (scriptDetector) Detect(s string) (string, bool) {
+	var han, kana, hangul, cyrillic, arabic, total int
+	for _, r := range s {
+		if unicode.IsSpace(r) || unicode.IsPunct(r) || unicode.IsNumber(r) {
+			continue
+		}
+		total++
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Cyrillic, r):
+			cyrillic++
+		case unicode.Is(unicode.Arabic, r):
+			arabic++
+		}
+	}
+	if total == 0 {
+		return "", false
+	}
+	switch {
+	case kana > 0 && float64(kana+han)/float64(total) > 0.3:
+		return "ja", true
+	case hangul > 0 && float64(hangul)/float64(total) > 0.3:
+		return "ko", true
+	case han > 0 && float64(han)/float64(total) > 0.3:
+		return "zh", true
+	case cyrillic > 0 && float64(cyrillic)/float64(total) > 0.3:
+		return "ru", true
+	case arabic > 0 && float64(arabic)/float64(total) > 0.3:
+		return "ar", true
+	}
+	return "", false
+}
@@ -0,0 +1,148 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMediaWorkers  = 4
+	defaultMediaInflight = defaultMediaWorkers * 2
+)
+
+// defaultVideoWorkers sizes the dedicated pool video jobs run on. Video
+// processing can hold a worker for minutes polling BlueSky's transcode job,
+// so it gets its own small pool instead of starving the download/upload
+// pool sized by 'a.Workers'.
+const defaultVideoWorkers = 1
+
+const mediaRetryMax = 3
+
+type mediaStatus uint8
+
+const (
+	mediaQueued mediaStatus = iota
+	mediaDownloading
+	mediaReady
+	mediaFailed
+)
+
+type mediaJob struct {
+	_      [0]func()
+	ctx    context.Context
+	work   func(context.Context) (interface{}, error)
+	done   chan mediaResult
+	URL    string
+	Type   string
+	status mediaStatus
+}
+type mediaResult struct {
+	Err   error
+	Value interface{}
+}
+
+// inflight only sizes the 'jobs' channel buffer (backpressure on submit);
+// 'workers' is the actual concurrency bound.
+type mediaWorker struct {
+	_    [0]func()
+	jobs chan *mediaJob
+	wg   sync.WaitGroup
+}
+
+func newMediaJob(x context.Context, url, typ string) *mediaJob {
+	return &mediaJob{ctx: x, URL: url, Type: typ, done: make(chan mediaResult, 1)}
+}
+func newMediaWorker(workers, inflight int) *mediaWorker {
+	if workers <= 0 {
+		workers = defaultMediaWorkers
+	}
+	if inflight <= 0 {
+		inflight = workers * 2
+	}
+	w := &mediaWorker{jobs: make(chan *mediaJob, inflight)}
+	w.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go w.run()
+	}
+	return w
+}
+func (w *mediaWorker) submit(j *mediaJob) {
+	j.status = mediaQueued
+	w.jobs <- j
+}
+func (w *mediaWorker) stop() {
+	close(w.jobs)
+	w.wg.Wait()
+}
+func (w *mediaWorker) run() {
+	defer w.wg.Done()
+	for j := range w.jobs {
+		j.status = mediaDownloading
+		v, err := mediaWithRetry(j.ctx, j.work)
+		if err != nil {
+			j.status = mediaFailed
+		} else {
+			j.status = mediaReady
+		}
+		j.done <- mediaResult{Value: v, Err: err}
+		close(j.done)
+	}
+}
+func mediaWithRetry(x context.Context, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	var err error
+	for i := 0; i < mediaRetryMax; i++ {
+		var v interface{}
+		if v, err = fn(x); err == nil {
+			return v, nil
+		}
+		if i == mediaRetryMax-1 || !mediaIsTransient(err) {
+			break
+		}
+		select {
+		case <-x.Done():
+			return nil, x.Err()
+		case <-time.After(time.Duration(i+1) * 250 * time.Millisecond):
+		}
+	}
+	return nil, err
+}
+func mediaIsTransient(err error) bool {
+	var n net.Error
+	if errors.As(err, &n) {
+		return n.Timeout()
+	}
+	var h *mediaHTTPError
+	if errors.As(err, &h) {
+		return h.Status == http.StatusTooManyRequests || h.Status >= 500
+	}
+	return false
+}
+
+type mediaHTTPError struct {
+	Status int
+}
+
+func (e *mediaHTTPError) Error() string {
+	return "unexpected HTTP status " + strconv.Itoa(e.Status)
+}
@@ -0,0 +1,245 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+)
+
+const verifyRemoteTimeout = time.Second * 10
+
+type micropubTarget struct {
+	UID  string `json:"uid"`
+	Name string `json:"name"`
+}
+
+type micropubServer struct {
+	_             [0]func()
+	srv           *http.Server
+	parent        *CrossPost
+	media         string
+	tokenEndpoint string
+}
+
+func (c *CrossPost) newMicropub(cfg *micropubConfig) *micropubServer {
+	if cfg == nil {
+		return nil
+	}
+	m := &micropubServer{parent: c, media: cfg.MediaEndpoint, tokenEndpoint: cfg.TokenEndpoint}
+	m.srv = &http.Server{Addr: cfg.Listen, Handler: m, ReadHeaderTimeout: time.Second * 10}
+	return m
+}
+func (m *micropubServer) start() {
+	m.parent.log.Info(`[micropub]: Starting Micropub endpoint on "%s"..`, m.srv.Addr)
+	go func() {
+		if err := m.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.parent.log.Error(`[micropub]: Endpoint on "%s" failed: %s!`, m.srv.Addr, err.Error())
+		}
+	}()
+}
+func (m *micropubServer) stop() {
+	m.srv.Close()
+}
+func (m *micropubServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		m.query(w, r)
+	case http.MethodPost:
+		m.submit(w, r)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *micropubServer) query(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Query().Get("q") {
+	case "config":
+		json.NewEncoder(w).Encode(struct {
+			Media       string           `json:"media-endpoint,omitempty"`
+			SyndicateTo []micropubTarget `json:"syndicate-to"`
+		}{Media: m.media, SyndicateTo: m.targets()})
+	case "syndicate-to":
+		json.NewEncoder(w).Encode(struct {
+			SyndicateTo []micropubTarget `json:"syndicate-to"`
+		}{SyndicateTo: m.targets()})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+func (m *micropubServer) targets() []micropubTarget {
+	t := make([]micropubTarget, 0, len(m.parent.accounts))
+	for _, p := range m.parent.accounts {
+		if len(p.mpToken) == 0 {
+			continue
+		}
+		t = append(t, micropubTarget{UID: p.name, Name: p.name})
+	}
+	return t
+}
+
+func (m *micropubServer) submit(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); strings.HasPrefix(ct, "multipart/form-data") {
+		_ = r.ParseMultipartForm(sizeMax * 10)
+	} else if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p, err := m.authenticate(r, r.FormValue("mp-destination"), bearerToken(r))
+	if err != nil {
+		m.parent.log.Debug(`[micropub]: Authentication failed: %s!`, err.Error())
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if h := r.FormValue("h"); len(h) > 0 && h != "entry" {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	d, err := p.buildMicropub(r)
+	if err != nil {
+		m.parent.log.Error(`[micropub/%s]: Cannot process submission: %s!`, p.name, err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	p.queue <- d
+	m.parent.log.Info(`[micropub/%s]: Accepted a post via Micropub.`, p.name)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (m *micropubServer) authenticate(r *http.Request, dest, token string) (*postAccount, error) {
+	if len(token) == 0 {
+		return nil, errors.New("missing bearer token")
+	}
+	var candidates []*postAccount
+	for _, p := range m.parent.accounts {
+		if len(p.mpToken) == 0 || (len(dest) > 0 && p.name != dest) {
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New(`no micropub-enabled account matches destination "` + dest + `"`)
+	}
+	for _, p := range candidates {
+		if subtle.ConstantTimeCompare([]byte(p.mpToken), []byte(token)) == 1 {
+			return p, nil
+		}
+	}
+	if len(m.tokenEndpoint) == 0 || !m.verifyRemote(r.Context(), token) {
+		return nil, errors.New("invalid token")
+	}
+	if len(candidates) != 1 {
+		return nil, errors.New(`token was verified remotely but the destination is ambiguous, specify "mp-destination"`)
+	}
+	return candidates[0], nil
+}
+
+func (m *micropubServer) verifyRemote(x context.Context, token string) bool {
+	x, cancel := context.WithTimeout(x, verifyRemoteTimeout)
+	defer cancel()
+	r, err := http.NewRequestWithContext(x, http.MethodGet, m.tokenEndpoint, nil)
+	if err != nil {
+		return false
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("Accept", "application/json")
+	o, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return false
+	}
+	o.Body.Close()
+	return o.StatusCode >= 200 && o.StatusCode < 300
+}
+func bearerToken(r *http.Request) string {
+	if a := r.Header.Get("Authorization"); strings.HasPrefix(a, "Bearer ") {
+		return strings.TrimPrefix(a, "Bearer ")
+	}
+	return r.FormValue("access_token")
+}
+
+func (p *postAccount) buildMicropub(r *http.Request) (postData, error) {
+	var b strings.Builder
+	b.WriteString(r.FormValue("content"))
+	for _, t := range r.Form["category[]"] {
+		if len(t) == 0 {
+			continue
+		}
+		b.WriteByte(' ')
+		b.WriteByte('#')
+		b.WriteString(t)
+	}
+	m, err := p.downloadMicropubMedia(r)
+	if err != nil {
+		return postData{}, err
+	}
+	i := mastodon.ID("micropub-" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	s := b.String()
+	var k string
+	if len(p.prefix) > 0 {
+		k = p.prefix + "/" + string(i)
+	}
+	return postData{ID: i, Content: s, Link: k, Media: m, Language: p.language(s, r.FormValue("mp-language"))}, nil
+}
+
+func (p *postAccount) downloadMicropubMedia(r *http.Request) ([]postMedia, error) {
+	if r.MultipartForm == nil {
+		return nil, nil
+	}
+	var a []postMedia
+	for _, field := range []string{"photo", "video"} {
+		for _, fh := range r.MultipartForm.File[field] {
+			k, err := p.saveMicropubFile(fh)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, k)
+		}
+	}
+	return a, nil
+}
+func (p *postAccount) saveMicropubFile(fh *multipart.FileHeader) (postMedia, error) {
+	s, err := fh.Open()
+	if err != nil {
+		return postMedia{}, errors.New(`media open "` + fh.Filename + `" failed: ` + err.Error())
+	}
+	f, err := os.CreateTemp("", "crosspost-media-*")
+	if err != nil {
+		s.Close()
+		return postMedia{}, errors.New(`media temp creation failed: ` + err.Error())
+	}
+	k := postMedia{File: f.Name()}
+	k.Size, err = io.Copy(f, s)
+	f.Close()
+	s.Close()
+	if err != nil {
+		os.Remove(k.File)
+		return postMedia{}, errors.New(`media save "` + fh.Filename + `" failed: ` + err.Error())
+	}
+	k.Type = sniffMediaType(k.File)
+	return k, nil
+}
@@ -34,9 +34,13 @@ import (
 var replacer = strings.NewReplacer("</p><p>", "\n\n</p><p>", "<br />", "\n", "<br/>", "\n", "<br>", "\n", "@twitter.com", "")
 
 type postData struct {
-	_       [0]func()
-	Media   []postMedia
-	Content string
+	_        [0]func()
+	ID       mastodon.ID
+	Media    []postMedia
+	Content  string
+	Link     string
+	Language string
+	Edit     bool
 }
 type postMedia struct {
 	_                [0]func()
@@ -44,16 +48,29 @@ type postMedia struct {
 	File, Text, Type string
 }
 type postAccount struct {
-	_      [0]func()
-	tw     *twClient
-	blue   *blueClient
-	http   *http.Client
-	name   string
-	repl   *strings.Replacer
-	user   mastodon.ID
-	masto  *mastodon.Client
-	parent *CrossPost
-	prefix string
+	_           [0]func()
+	tw          *twClient
+	blue        *blueClient
+	feed        *feedSink
+	http        *http.Client
+	name        string
+	replace     []replaceRule
+	user        mastodon.ID
+	masto       *mastodon.Client
+	media       *mediaWorker
+	video       *mediaWorker
+	store       *postStore
+	parent      *CrossPost
+	queue       chan postData
+	prefix      string
+	editMode    string
+	lang        string
+	mpToken     string
+	detect      languageDetector
+	langInclude []string
+	langExclude []string
+	tagInclude  []string
+	tagExclude  []string
 }
 
 func (p postData) close() {
@@ -111,9 +128,8 @@ func (p *postAccount) start(x context.Context, g *sync.WaitGroup) error {
 	p.parent.log.Info(`[poster/%s]: Starting receiver and listener threads..`, p.name)
 	g.Go(func() {
 		g.Add(1)
-		o := make(chan postData, 16)
-		go p.post(x, g, o)
-		p.listen(x, o, s)
+		go p.post(x, g, p.queue)
+		p.listen(x, p.queue, s)
 		p.parent.log.Debug(`[poster/%s]: Cleaning up..`, p.name)
 		g.Done()
 	})
@@ -128,22 +144,96 @@ func (p *postAccount) post(x context.Context, g *sync.WaitGroup, o <-chan postDa
 			g.Done()
 			return
 		case d := <-o:
-			/*if p.tw != nil {
-				p.parent.log.Trace(`[poster/%s]: Sending post to Twitter poster..`, p.name)
-				if err := p.tw.post(x, &d); err != nil {
-					p.parent.log.Debug(`[poster/%s]: Twitter post failed: %s!`, p.name, err.Error())
-				}
-			}*/
-			if p.blue != nil {
-				p.parent.log.Trace(`[poster/%s]: Sending post to BlueSky poster..`, p.name)
-				if err := p.blue.post(x, &d); err != nil {
-					p.parent.log.Debug(`[poster/%s]: BlueSky post failed: %s!`, p.name, err.Error())
-				}
+			if d.Edit {
+				p.dispatchEdit(x, &d)
+			} else {
+				p.dispatchNew(x, &d)
 			}
 			d.close()
 		}
 	}
 }
+
+func (p *postAccount) dispatchNew(x context.Context, d *postData) {
+	if p.tw != nil {
+		p.parent.log.Trace(`[poster/%s]: Sending post to Twitter poster..`, p.name)
+		t := *d
+		t.Content = p.applyReplace(d.Content, replaceTargetTwitter)
+		if id, err := p.tw.post(x, &t); err != nil {
+			p.parent.log.Debug(`[poster/%s]: Twitter post failed: %s!`, p.name, err.Error())
+		} else if p.store != nil {
+			p.store.update(d.ID, func(r *postRecord) { r.TweetID = id })
+		}
+	}
+	if p.blue != nil {
+		p.parent.log.Trace(`[poster/%s]: Sending post to BlueSky poster..`, p.name)
+		b := *d
+		b.Content = p.applyReplace(d.Content, replaceTargetBlue)
+		u, i, err := p.blue.post(x, &b)
+		if err != nil {
+			if len(u) > 0 {
+				p.parent.log.Error(`[poster/%s]: BlueSky post failed: %s! Partial thread root "%s" was already posted and needs manual cleanup.`, p.name, err.Error(), u)
+				if p.store != nil {
+					p.store.update(d.ID, func(r *postRecord) { r.BlueURI, r.BlueCID = u, i })
+				}
+			} else {
+				p.parent.log.Debug(`[poster/%s]: BlueSky post failed: %s!`, p.name, err.Error())
+			}
+		} else if p.store != nil {
+			p.store.update(d.ID, func(r *postRecord) { r.BlueURI, r.BlueCID = u, i })
+		}
+	}
+	if p.feed != nil {
+		p.parent.log.Trace(`[poster/%s]: Adding post to feed sink..`, p.name)
+		f := *d
+		f.Content = p.applyReplace(d.Content, replaceTargetFeed)
+		p.feed.add(&f)
+	}
+}
+
+func (p *postAccount) dispatchEdit(x context.Context, d *postData) {
+	if p.editMode == editModeIgnore {
+		return
+	}
+	if p.editMode == editModeAppendNote {
+		d.Content += " (edited)"
+	}
+	if p.feed != nil {
+		f := *d
+		f.Content = p.applyReplace(d.Content, replaceTargetFeed)
+		p.feed.update(&f)
+	}
+	if p.store == nil {
+		return
+	}
+	r, ok := p.store.get(d.ID)
+	if !ok {
+		p.parent.log.Debug(`[poster/%s/%s]: Ignoring edit for an untracked status..`, p.name, d.ID)
+		return
+	}
+	if p.blue != nil && len(r.BlueURI) > 0 {
+		p.parent.log.Trace(`[poster/%s]: Sending edit to BlueSky poster..`, p.name)
+		b := *d
+		b.Content = p.applyReplace(d.Content, replaceTargetBlue)
+		u, i, err := p.blue.edit(x, r.BlueURI, &b)
+		if err != nil {
+			p.parent.log.Error(`[poster/%s]: BlueSky edit failed: %s!`, p.name, err.Error())
+		} else {
+			p.store.update(d.ID, func(r *postRecord) { r.BlueURI, r.BlueCID = u, i })
+		}
+	}
+	if p.tw != nil && len(r.TweetID) > 0 {
+		p.parent.log.Trace(`[poster/%s]: Sending edit to Twitter poster..`, p.name)
+		t := *d
+		t.Content = p.applyReplace(d.Content, replaceTargetTwitter)
+		id, err := p.tw.edit(x, r.TweetID, &t)
+		if err != nil {
+			p.parent.log.Debug(`[poster/%s]: Twitter edit failed: %s!`, p.name, err.Error())
+		} else {
+			p.store.update(d.ID, func(r *postRecord) { r.TweetID = id })
+		}
+	}
+}
 func (p *postAccount) handle(x context.Context, o chan<- postData, e *mastodon.Status) {
 	p.parent.log.Trace(`[poster/%s]: Received status "%s" from stream..`, p.name, e.ID)
 	if e.Account.ID != p.user {
@@ -154,21 +244,114 @@ func (p *postAccount) handle(x context.Context, o chan<- postData, e *mastodon.S
 		p.parent.log.Debug(`[poster/%s]: Ignoring status from "%s" as it does not match the content criteria..`, p.name, e.ID)
 		return
 	}
-	m, err := p.download(x, e.ID, e.MediaAttachments)
+	if !p.languageAllowed(e.Language) {
+		p.parent.log.Debug(`[poster/%s]: Ignoring status "%s", its language "%s" is filtered out..`, p.name, e.ID, e.Language)
+		return
+	}
+	if !p.tagsAllowed(statusTags(e)) {
+		p.parent.log.Debug(`[poster/%s]: Ignoring status "%s", its hashtags are filtered out..`, p.name, e.ID)
+		return
+	}
+	d, err := p.build(x, e)
 	if err != nil {
 		p.parent.log.Error(`[poster/%s/%s]: Cannot download attachments: %s!`, p.name, e.ID, err.Error())
 		return
 	}
-	s := stripHTML(replacer.Replace(e.Content))
-	if p.repl != nil {
-		s = p.repl.Replace(s)
+	o <- d
+	p.parent.log.Debug(`[poster/%s/%s]: Sent post to receivers!`, p.name, e.ID)
+}
+
+func (p *postAccount) handleEdit(x context.Context, o chan<- postData, e *mastodon.Status) {
+	p.parent.log.Trace(`[poster/%s]: Received status edit "%s" from stream..`, p.name, e.ID)
+	if e.Account.ID != p.user {
+		p.parent.log.Debug(`[poster/%s]: Ignoring status edit from "%s" as it's not from "%s"..`, p.name, e.Account.ID, p.user)
+		return
+	}
+	if !p.languageAllowed(e.Language) {
+		p.parent.log.Debug(`[poster/%s]: Ignoring status edit "%s", its language "%s" is filtered out..`, p.name, e.ID, e.Language)
+		return
+	}
+	if !p.tagsAllowed(statusTags(e)) {
+		p.parent.log.Debug(`[poster/%s]: Ignoring status edit "%s", its hashtags are filtered out..`, p.name, e.ID)
+		return
+	}
+	d, err := p.build(x, e)
+	if err != nil {
+		p.parent.log.Error(`[poster/%s/%s]: Cannot download attachments: %s!`, p.name, e.ID, err.Error())
+		return
+	}
+	d.Edit = true
+	o <- d
+	p.parent.log.Debug(`[poster/%s/%s]: Sent edit to receivers!`, p.name, e.ID)
+}
+
+func (p *postAccount) build(x context.Context, e *mastodon.Status) (postData, error) {
+	m, err := p.download(x, e.ID, e.MediaAttachments)
+	if err != nil {
+		return postData{}, err
 	}
+	s := stripHTML(replacer.Replace(e.Content))
 	var k string
 	if len(p.prefix) > 0 {
 		k = p.prefix + "/" + string(e.ID)
 	}
-	o <- postData{Content: makeStringWithPrefix(s, k), Media: m}
-	p.parent.log.Debug(`[poster/%s/%s]: Sent post to receivers!`, p.name, e.ID)
+	return postData{ID: e.ID, Content: s, Link: k, Media: m, Language: p.language(s, e.Language)}, nil
+}
+
+func (p *postAccount) applyReplace(s, target string) string {
+	for i := range p.replace {
+		r := &p.replace[i]
+		if len(r.Targets) > 0 {
+			var match bool
+			for _, t := range r.Targets {
+				if t == target {
+					match = true
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+		}
+		if r.re != nil {
+			s = r.re.ReplaceAllString(s, r.Replacement)
+		} else {
+			s = strings.ReplaceAll(s, r.Pattern, r.Replacement)
+		}
+	}
+	return s
+}
+
+func (p *postAccount) languageAllowed(lang string) bool {
+	if len(lang) == 0 || (len(p.langInclude) == 0 && len(p.langExclude) == 0) {
+		return true
+	}
+	if langMatches(lang, p.langExclude) {
+		return false
+	}
+	return len(p.langInclude) == 0 || langMatches(lang, p.langInclude)
+}
+
+func (p *postAccount) tagsAllowed(t map[string]struct{}) bool {
+	if len(p.tagInclude) == 0 && len(p.tagExclude) == 0 {
+		return true
+	}
+	if tagMatches(t, p.tagExclude) {
+		return false
+	}
+	return len(p.tagInclude) == 0 || tagMatches(t, p.tagInclude)
+}
+
+func (p *postAccount) language(content, mastodonLang string) string {
+	if len(mastodonLang) > 0 {
+		return mastodonLang
+	}
+	if p.detect != nil {
+		if l, ok := p.detect.Detect(content); ok {
+			return l
+		}
+	}
+	return p.lang
 }
 func (c *CrossPost) newPostAccount(x context.Context, a *account, d time.Duration) error {
 	m := mastodon.NewClient(&mastodon.Config{
@@ -181,14 +364,27 @@ func (c *CrossPost) newPostAccount(x context.Context, a *account, d time.Duratio
 	if err != nil {
 		return errors.New("mastodon client setup failed: " + err.Error())
 	}
+	s, err := newPostStore(a.State, v.Username)
+	if err != nil {
+		return errors.New(`state store "` + a.State + `" setup failed: ` + err.Error())
+	}
 	p := &postAccount{
-		tw:     nil,
-		blue:   nil,
-		user:   v.ID,
-		name:   v.Username,
-		masto:  m,
-		prefix: a.Prefix,
-		parent: c,
+		tw:       nil,
+		blue:     nil,
+		feed:     newFeedSink(a.Feed),
+		user:     v.ID,
+		name:     v.Username,
+		masto:    m,
+		media:    newMediaWorker(a.Workers, 0),
+		video:    newMediaWorker(defaultVideoWorkers, 0),
+		store:    s,
+		queue:    make(chan postData, 16),
+		prefix:   a.Prefix,
+		parent:   c,
+		editMode: a.EditMode,
+		lang:     a.Language,
+		mpToken:  a.MicropubToken,
+		detect:   defaultLanguageDetector,
 		http: &http.Client{
 			Timeout: d,
 			Transport: &http.Transport{
@@ -202,20 +398,19 @@ func (c *CrossPost) newPostAccount(x context.Context, a *account, d time.Duratio
 				ExpectContinueTimeout: d,
 				ResponseHeaderTimeout: d,
 			},
-		}}
+		},
+	}
+	if a.Languages != nil {
+		p.langInclude, p.langExclude = normalizeLangs(a.Languages.Include), normalizeLangs(a.Languages.Exclude)
+	}
+	p.tagInclude, p.tagExclude = a.IncludeTags, a.ExcludeTags
 	if err = p.newBlue(a.Blue, d, p.http); err != nil {
 		return err
 	}
 	if err = p.newTwitter(a.Twitter, d, p.http); err != nil {
 		return err
 	}
-	if len(a.Replace) > 0 {
-		r := make([]string, 0, len(a.Replace)*2)
-		for k, v := range a.Replace {
-			r = append(r, k, v)
-		}
-		p.repl = strings.NewReplacer(r...)
-	}
+	p.replace = a.Replace
 	c.accounts = append(c.accounts, p)
 	return nil
 }
@@ -232,6 +427,8 @@ func (p *postAccount) listen(x context.Context, o chan<- postData, i <-chan mast
 				p.parent.log.Error(`[poster/%s]: Received an error from the stream: %s!`, p.name, v.Err.Error())
 			case *mastodon.UpdateEvent:
 				p.handle(x, o, v.Status)
+			case *mastodon.UpdateEditEvent:
+				p.handleEdit(x, o, v.Status)
 			default:
 			}
 		}
@@ -242,48 +439,70 @@ func (p *postAccount) download(x context.Context, i mastodon.ID, m []mastodon.At
 		return nil, nil
 	}
 	p.parent.log.Debug(`[poster/%s/%s]: Processing attachments..`, p.name, i)
-	a := make([]postMedia, 0, len(m))
+	j := make([]*mediaJob, 0, len(m))
 	for _, v := range m {
 		if len(v.URL) == 0 || len(v.Type) == 0 || (v.Type != "image" && v.Type != "gif" && v.Type != "video") {
 			continue
 		}
-		f, err := os.CreateTemp("", "crosspost-media-*")
-		if err != nil {
-			return nil, errors.New(`media temp creation failed: ` + err.Error())
-		}
-		var (
-			r, _ = http.NewRequestWithContext(x, "GET", v.URL, nil)
-			k    = postMedia{File: f.Name(), Text: v.Description}
-			d    bool
-		)
-		switch v.Type {
-		case "gif", "video":
-			k.Type = "video/mp4"
-		default:
-			k.Type = "image/jpeg"
+		k := newMediaJob(x, v.URL, v.Type)
+		k.work = func(c context.Context) (interface{}, error) {
+			return p.downloadAttachment(c, i, v)
 		}
-		p.parent.log.Debug(`[poster/%s/%s]: Downloading attachment "%s" (%s) into "%s"..`, p.name, i, v.URL, v.Type, k.File)
-		if o, err := p.http.Do(r); err == nil {
-			if o.Body != nil {
-				if k.Size, err = io.Copy(f, o.Body); err == nil {
-					d = true
-				} else {
-					p.parent.log.Error(`[poster/%s/%s]: Cannot download attachment from "%s" into file "%s": %s!`, p.name, i, v.URL, k.File, err.Error())
-				}
-			}
-		} else {
-			p.parent.log.Error(`[poster/%s/%s]: Cannot download attachment from "%s": %s!`, p.name, i, v.URL, k.File, err.Error())
-		}
-		if f.Close(); !d {
-			os.Remove(k.File)
+		p.media.submit(k)
+		j = append(j, k)
+	}
+	a := make([]postMedia, 0, len(j))
+	for _, k := range j {
+		r := <-k.done
+		if r.Err != nil {
+			p.parent.log.Error(`[poster/%s/%s]: Cannot download attachment from "%s": %s!`, p.name, i, k.URL, r.Err.Error())
 			continue
 		}
-		p.parent.log.Debug(`[poster/%s/%s]: Download of attachment "%s" into "%s" completed successfully!`, p.name, i, v.URL, k.File)
-		if len(v.Type) == 0 {
-			v.Type = "image/jpeg"
-		}
-		a = append(a, k)
+		a = append(a, r.Value.(postMedia))
 	}
 	p.parent.log.Debug(`[poster/%s/%s]: Processed %d attachments.`, p.name, i, len(a))
 	return a, nil
 }
+
+func (p *postAccount) downloadAttachment(x context.Context, i mastodon.ID, v mastodon.Attachment) (postMedia, error) {
+	f, err := os.CreateTemp("", "crosspost-media-*")
+	if err != nil {
+		return postMedia{}, errors.New(`media temp creation failed: ` + err.Error())
+	}
+	var (
+		r, _ = http.NewRequestWithContext(x, "GET", v.URL, nil)
+		k    = postMedia{File: f.Name(), Text: v.Description}
+		d    bool
+	)
+	p.parent.log.Debug(`[poster/%s/%s]: Downloading attachment "%s" (%s) into "%s"..`, p.name, i, v.URL, v.Type, k.File)
+	o, err := p.http.Do(r)
+	if err == nil && o.Body != nil {
+		if o.StatusCode < 200 || o.StatusCode > 299 {
+			err = &mediaHTTPError{Status: o.StatusCode}
+		} else if k.Size, err = io.Copy(f, o.Body); err == nil {
+			d = true
+		}
+		o.Body.Close()
+	}
+	if f.Close(); !d {
+		os.Remove(k.File)
+		if err == nil {
+			err = errors.New(`download of "` + v.URL + `" produced no data`)
+		}
+		return postMedia{}, errors.New(`media download failed: ` + err.Error())
+	}
+	k.Type = sniffMediaType(k.File)
+	p.parent.log.Debug(`[poster/%s/%s]: Download of attachment "%s" into "%s" completed successfully (detected "%s")!`, p.name, i, v.URL, k.File, k.Type)
+	return k, nil
+}
+
+func sniffMediaType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	var b [512]byte
+	n, _ := f.Read(b[:])
+	f.Close()
+	return http.DetectContentType(b[:n])
+}
@@ -0,0 +1,46 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"context"
+	"sort"
+	"unicode/utf8"
+)
+
+func (c *blueClient) buildFacets(x context.Context, s string) []blueFacet {
+	r := c.facetTags(s, nil)
+	r = c.facetURLs(s, r)
+	r = c.facetMentions(x, s, r)
+	r = validateFacets(s, r)
+	sort.Slice(r, func(i, j int) bool { return r[i].Index.Start < r[j].Index.Start })
+	return r
+}
+
+func validateFacets(s string, f []blueFacet) []blueFacet {
+	o := f[:0]
+	for _, v := range f {
+		if v.Index.Start < 0 || v.Index.End > len(s) || v.Index.Start >= v.Index.End {
+			continue
+		}
+		if !utf8.RuneStart(s[v.Index.Start]) || (v.Index.End < len(s) && !utf8.RuneStart(s[v.Index.End])) {
+			continue
+		}
+		o = append(o, v)
+	}
+	return o
+}
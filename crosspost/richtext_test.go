@@ -0,0 +1,133 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"context"
+	"testing"
+)
+
+type stubResolver map[string]string
+
+func (s stubResolver) findUser(_ context.Context, n string) string {
+	return s[n]
+}
+
+func TestFacetTags(t *testing.T) {
+	var c blueClient
+	tests := []struct {
+		name string
+		in   string
+		tag  string
+	}{
+		{name: "ascii", in: "check out #golang today", tag: "#golang"},
+		{name: "emoji prefix", in: "😀 launch day #🚀rocket", tag: "#🚀rocket"},
+		{name: "cjk", in: "発表します #発表", tag: "#発表"},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			r := c.facetTags(v.in, nil)
+			if len(r) != 1 {
+				t.Fatalf("facetTags(%q) = %d facets, want 1", v.in, len(r))
+			}
+			if s := v.in[r[0].Index.Start:r[0].Index.End]; s != v.tag {
+				t.Fatalf("facetTags(%q) range = %q, want %q", v.in, s, v.tag)
+			}
+			if got := "#" + r[0].Features[0].Tag; got != v.tag {
+				t.Fatalf("facetTags(%q) tag = %q, want %q", v.in, got, v.tag)
+			}
+		})
+	}
+}
+func TestFacetURLs(t *testing.T) {
+	var c blueClient
+	tests := []struct {
+		name string
+		in   string
+		url  string
+	}{
+		{name: "ascii", in: "see https://example.com/path for details", url: "https://example.com/path"},
+		{name: "emoji before", in: "🎉 https://example.com/launch 🎉", url: "https://example.com/launch"},
+		{name: "cjk before", in: "発表です https://example.com/launch です", url: "https://example.com/launch"},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			r := c.facetURLs(v.in, nil)
+			if len(r) != 1 {
+				t.Fatalf("facetURLs(%q) = %d facets, want 1", v.in, len(r))
+			}
+			if s := v.in[r[0].Index.Start:r[0].Index.End]; s != v.url {
+				t.Fatalf("facetURLs(%q) range = %q, want %q", v.in, s, v.url)
+			}
+			if got := r[0].Features[0].URL; got != v.url {
+				t.Fatalf("facetURLs(%q) url = %q, want %q", v.in, got, v.url)
+			}
+		})
+	}
+}
+func TestFacetMentions(t *testing.T) {
+	tests := []struct {
+		name   string
+		in     string
+		handle string
+		did    string
+	}{
+		{name: "ascii", in: "ping @alice.bsky.social please", handle: "alice.bsky.social", did: "did:plc:alice"},
+		{name: "emoji before", in: "🎉 @alice.bsky.social 🎉", handle: "alice.bsky.social", did: "did:plc:alice"},
+		{name: "cjk before", in: "発表です @alice.bsky.social です", handle: "alice.bsky.social", did: "did:plc:alice"},
+	}
+	for _, v := range tests {
+		t.Run(v.name, func(t *testing.T) {
+			c := blueClient{resolver: stubResolver{v.handle: v.did}}
+			r := c.facetMentions(context.Background(), v.in, nil)
+			if len(r) != 1 {
+				t.Fatalf("facetMentions(%q) = %d facets, want 1", v.in, len(r))
+			}
+			if s := v.in[r[0].Index.Start:r[0].Index.End]; s != "@"+v.handle {
+				t.Fatalf("facetMentions(%q) range = %q, want %q", v.in, s, "@"+v.handle)
+			}
+			if got := r[0].Features[0].Did; got != v.did {
+				t.Fatalf("facetMentions(%q) did = %q, want %q", v.in, got, v.did)
+			}
+		})
+	}
+	t.Run("unresolvable handle dropped", func(t *testing.T) {
+		c := blueClient{resolver: stubResolver{}}
+		if r := c.facetMentions(context.Background(), "ping @nobody.bsky.social", nil); len(r) != 0 {
+			t.Fatalf("facetMentions() = %d facets, want 0 for an unresolvable handle", len(r))
+		}
+	})
+}
+func TestValidateFacets(t *testing.T) {
+	s := "😀 #tag"
+	f := []blueFacet{
+		{}, // Index is set for each case below.
+	}
+	f[0].Index.Start, f[0].Index.End = 1, 3 // Splits the emoji's multi-byte rune in half.
+	if r := validateFacets(s, f); len(r) != 0 {
+		t.Fatalf("validateFacets(%q) = %d facets, want 0 for a mid-rune range", s, len(r))
+	}
+	f[0].Index.Start, f[0].Index.End = len(s), len(s)
+	if r := validateFacets(s, f); len(r) != 0 {
+		t.Fatalf("validateFacets(%q) = %d facets, want 0 for an empty range", s, len(r))
+	}
+	var c blueClient
+	valid := c.facetTags(s, nil)
+	if r := validateFacets(s, valid); len(r) != len(valid) {
+		t.Fatalf("validateFacets(%q) dropped a valid facet: got %d, want %d", s, len(r), len(valid))
+	}
+}
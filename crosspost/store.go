@@ -0,0 +1,107 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mattn/go-mastodon"
+	"go.etcd.io/bbolt"
+)
+
+type postRecord struct {
+	BlueURI string `json:"blue_uri,omitempty"`
+	BlueCID string `json:"blue_cid,omitempty"`
+	TweetID string `json:"tweet_id,omitempty"`
+}
+
+type postStore struct {
+	_      [0]func()
+	db     *bbolt.DB
+	bucket []byte
+}
+
+func newPostStore(path, account string) (*postStore, error) {
+	if len(path) == 0 {
+		return &postStore{}, nil
+	}
+	d, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	s := &postStore{db: d, bucket: []byte(account)}
+	if err = d.Update(func(x *bbolt.Tx) error {
+		_, err := x.CreateBucketIfNotExists(s.bucket)
+		return err
+	}); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return s, nil
+}
+func (s *postStore) get(i mastodon.ID) (postRecord, bool) {
+	if s.db == nil {
+		return postRecord{}, false
+	}
+	var (
+		r  postRecord
+		ok bool
+	)
+	s.db.View(func(x *bbolt.Tx) error {
+		b := x.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		v := b.Get([]byte(i))
+		if v == nil {
+			return nil
+		}
+		ok = json.Unmarshal(v, &r) == nil
+		return nil
+	})
+	return r, ok
+}
+
+func (s *postStore) update(i mastodon.ID, fn func(*postRecord)) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Update(func(x *bbolt.Tx) error {
+		b, err := x.CreateBucketIfNotExists(s.bucket)
+		if err != nil {
+			return err
+		}
+		var r postRecord
+		if v := b.Get([]byte(i)); v != nil {
+			json.Unmarshal(v, &r)
+		}
+		fn(&r)
+		d, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(i), d)
+	})
+}
+
+func (s *postStore) close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
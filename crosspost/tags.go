@@ -0,0 +1,57 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"strings"
+
+	"github.com/mattn/go-mastodon"
+)
+
+func normalizeTags(l []string) []string {
+	if len(l) == 0 {
+		return nil
+	}
+	o := make([]string, 0, len(l))
+	for _, v := range l {
+		v = strings.ToLower(strings.TrimPrefix(v, "#"))
+		if len(v) == 0 {
+			continue
+		}
+		o = append(o, v)
+	}
+	return o
+}
+
+func statusTags(e *mastodon.Status) map[string]struct{} {
+	t := make(map[string]struct{}, len(e.Tags))
+	for _, v := range e.Tags {
+		if len(v.Name) > 0 {
+			t[strings.ToLower(v.Name)] = struct{}{}
+		}
+	}
+	return t
+}
+
+func tagMatches(t map[string]struct{}, list []string) bool {
+	for _, v := range list {
+		if _, ok := t[v]; ok {
+			return true
+		}
+	}
+	return false
+}
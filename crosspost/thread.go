@@ -0,0 +1,132 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/rivo/uniseg"
+)
+
+const blueMaxGraphemes = 300
+
+const blueCounterReserve = 10
+
+type blueReply struct {
+	Root   blueStrongRef `json:"root"`
+	Parent blueStrongRef `json:"parent"`
+}
+type blueStrongRef struct {
+	URI string `json:"uri"`
+	CID string `json:"cid"`
+}
+
+func graphemeLen(s string) int {
+	return uniseg.GraphemeClusterCount(s)
+}
+
+func withLink(s, link string) string {
+	switch {
+	case len(link) == 0:
+		return s
+	case len(s) == 0:
+		return link
+	default:
+		return s + " " + link
+	}
+}
+
+func buildThreadSegments(content, link string) []string {
+	if graphemeLen(withLink(content, link)) <= blueMaxGraphemes {
+		return []string{withLink(content, link)}
+	}
+	parts := splitGraphemes(content, blueMaxGraphemes-blueCounterReserve)
+	if len(parts) == 0 {
+		parts = []string{""}
+	}
+	out := make([]string, len(parts))
+	for i, p := range parts {
+		out[i] = p + " (" + strconv.Itoa(i+1) + "/" + strconv.Itoa(len(parts)) + ")"
+	}
+	out[len(out)-1] = withLink(out[len(out)-1], link)
+	if graphemeLen(out[len(out)-1]) > blueMaxGraphemes {
+		out[len(out)-1] = strings.TrimSuffix(out[len(out)-1], " "+link)
+		out = append(out, link)
+	}
+	return out
+}
+
+func splitGraphemes(s string, max int) []string {
+	if max <= 0 {
+		max = 1
+	}
+	var (
+		clusters  []string
+		sentence  []bool
+		lineBreak []bool
+	)
+	for g := uniseg.NewGraphemes(s); g.Next(); {
+		clusters = append(clusters, g.Str())
+		sentence = append(sentence, g.IsSentenceBoundary())
+		lineBreak = append(lineBreak, g.LineBreak() != uniseg.LineDontBreak)
+	}
+	if len(clusters) == 0 {
+		return nil
+	}
+	var out []string
+	for start := 0; start < len(clusters); {
+		if len(clusters)-start <= max {
+			out = append(out, trimSegment(clusters[start:]))
+			break
+		}
+		end := start + max
+		cut := bestCut(sentence, lineBreak, start, end)
+		out = append(out, trimSegment(clusters[start:cut]))
+		for cut < len(clusters) && isBreakableCluster(clusters[cut]) {
+			cut++
+		}
+		start = cut
+	}
+	return out
+}
+
+func bestCut(sentence, lineBreak []bool, start, end int) int {
+	min := start + (end-start)/2
+	for i := end; i > min; i-- {
+		if sentence[i-1] {
+			return i
+		}
+	}
+	for i := end; i > min; i-- {
+		if lineBreak[i-1] {
+			return i
+		}
+	}
+	return end
+}
+func trimSegment(c []string) string {
+	return strings.TrimRight(strings.Join(c, ""), " \t\r\n")
+}
+func isBreakableCluster(c string) bool {
+	for _, r := range c {
+		if r != ' ' && r != '\t' && r != '\r' && r != '\n' {
+			return false
+		}
+	}
+	return len(c) > 0
+}
@@ -37,32 +37,65 @@ type twClient struct {
 	poster *postAccount
 }
 
-func (c *twClient) post(x context.Context, d *postData) error {
+func (c *twClient) post(x context.Context, d *postData) (string, error) {
 	c.poster.parent.log.Debug(`[poster/%s/twitter]: Received post..`, c.poster.name)
-	m := make([]string, 0, len(d.Media))
+	var m []string
 	if len(d.Media) > 0 {
 		c.poster.parent.log.Debug(`[poster/%s/twitter]: Post has media, processing %d attachments..`, c.poster.name, len(d.Media))
-		for i := range d.Media {
-			r, err := c.postMedia(x, &d.Media[i])
-			if err != nil {
-				return errors.New("media initialize failed: " + err.Error())
-			}
-			m = append(m, r)
+		r, err := c.postMedia(x, d.Media)
+		if err != nil {
+			return "", errors.New("media initialize failed: " + err.Error())
 		}
+		m = r
 	}
 	var v *tweet.CreateInputMedia
 	if len(m) > 0 {
 		v = &tweet.CreateInputMedia{MediaIDs: m}
 	}
+	t := makeStringWithPrefix(d.Content, d.Link)
 	c.poster.parent.log.Debug(`[poster/%s/twitter]: Posting Tweet..`, c.poster.name)
-	r, err := managetweet.Create(x, c.tw, &tweet.CreateInput{Text: &d.Content, Media: v})
+	r, err := managetweet.Create(x, c.tw, &tweet.CreateInput{Text: &t, Media: v})
 	if err != nil {
-		return err
+		return "", err
 	}
 	c.poster.parent.log.Info(`[poster/%s/twitter]: Posted Tweet "%s"!`, c.poster.name, *r.Data.ID)
-	return nil
+	return *r.Data.ID, nil
+}
+
+func (c *twClient) edit(x context.Context, id string, d *postData) (string, error) {
+	c.poster.parent.log.Debug(`[poster/%s/twitter]: Editing Tweet "%s"..`, c.poster.name, id)
+	if _, err := managetweet.Delete(x, c.tw, &tweet.DeleteInput{ID: id}); err != nil {
+		c.poster.parent.log.Debug(`[poster/%s/twitter]: Delete of Tweet "%s" failed (it may already be gone): %s!`, c.poster.name, id, err.Error())
+	}
+	n, err := c.post(x, d)
+	if err != nil {
+		return "", err
+	}
+	c.poster.parent.log.Info(`[poster/%s/twitter]: Replaced Tweet "%s" with "%s"!`, c.poster.name, id, n)
+	return n, nil
+}
+
+func (c *twClient) postMedia(x context.Context, media []postMedia) ([]string, error) {
+	j := make([]*mediaJob, len(media))
+	for i := range media {
+		k := newMediaJob(x, media[i].File, media[i].Type)
+		k.work = func(c2 context.Context) (interface{}, error) {
+			return c.uploadMedia(c2, &media[i])
+		}
+		c.poster.media.submit(k)
+		j[i] = k
+	}
+	r := make([]string, len(j))
+	for i := range j {
+		o := <-j[i].done
+		if o.Err != nil {
+			return nil, o.Err
+		}
+		r[i] = o.Value.(string)
+	}
+	return r, nil
 }
-func (c *twClient) postMedia(x context.Context, m *postMedia) (string, error) {
+func (c *twClient) uploadMedia(x context.Context, m *postMedia) (string, error) {
 	t := types.MediaCategoryTweetImage
 	if strings.HasPrefix(m.Type, "video/") {
 		t = types.MediaCategoryTweetVideo
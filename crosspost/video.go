@@ -0,0 +1,162 @@
+// Copyright (C) 2021 - 2025 PurpleSec Team
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <https://www.gnu.org/licenses/>.
+//
+
+package crosspost
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+const blueVideoServer = "video.bsky.app"
+
+const (
+	blueVideoJobComplete = "JOB_STATE_COMPLETED"
+	blueVideoJobFailed   = "JOB_STATE_FAILED"
+)
+
+const blueVideoMaxSize = int64(100_000_000)
+
+const (
+	blueVideoPollDelay = 2 * time.Second
+	blueVideoPollMax   = 150
+)
+
+type blueVideoLimits struct {
+	CanUpload bool   `json:"canUpload"`
+	Error     string `json:"error"`
+}
+type blueVideoJob struct {
+	JobID string    `json:"jobId"`
+	State string    `json:"state"`
+	Blob  *blueBlob `json:"blob"`
+	Error string    `json:"error"`
+}
+
+func (c *blueClient) postVideo(x context.Context, m *postMedia) (*blueBlob, error) {
+	j := newMediaJob(x, m.File, m.Type)
+	j.work = func(c2 context.Context) (interface{}, error) {
+		return c.processVideo(c2, m)
+	}
+	c.poster.video.submit(j)
+	r := <-j.done
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	return r.Value.(*blueBlob), nil
+}
+
+func (c *blueClient) processVideo(x context.Context, m *postMedia) (*blueBlob, error) {
+	if m.Size > blueVideoMaxSize {
+		return nil, errors.New(`video "` + m.File + `" (` + strconv.FormatInt(m.Size, 10) + `b) exceeds the BlueSky video size limit; re-encode or drop it`)
+	}
+	if err := c.checkVideoLimits(x); err != nil {
+		return nil, err
+	}
+	j, err := c.uploadVideo(x, m)
+	if err != nil {
+		return nil, err
+	}
+	return c.waitForVideoJob(x, j.JobID)
+}
+func (c *blueClient) checkVideoLimits(x context.Context) error {
+	var r blueVideoLimits
+	if err := c.videoAPI(x, http.MethodGet, "app.bsky.video.getUploadLimits", &r); err != nil {
+		return errors.New("video upload limits check failed: " + err.Error())
+	}
+	if len(r.Error) > 0 {
+		return errors.New("video upload limits check failed: " + r.Error)
+	}
+	if !r.CanUpload {
+		return errors.New("video upload quota exceeded for this account")
+	}
+	return nil
+}
+func (c *blueClient) uploadVideo(x context.Context, m *postMedia) (*blueVideoJob, error) {
+	f, err := os.Open(m.File)
+	if err != nil {
+		return nil, errors.New(`media open "` + m.File + `" failed: ` + err.Error())
+	}
+	var (
+		j    blueVideoJob
+		path = "app.bsky.video.uploadVideo?did=" + url.QueryEscape(c.id) + "&name=" + url.QueryEscape(filepath.Base(m.File))
+	)
+	r, _ := http.NewRequestWithContext(x, http.MethodPost, "https://"+blueVideoServer+"/xrpc/"+path, f)
+	if len(c.token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if len(m.Type) > 0 {
+		r.Header.Set("Content-Type", m.Type)
+	}
+	err = c.videoDo(r, &j)
+	if f.Close(); err != nil {
+		return nil, errors.New("video upload failed: " + err.Error())
+	}
+	if len(j.Error) > 0 {
+		return nil, errors.New("video upload failed: " + j.Error)
+	}
+	return &j, nil
+}
+
+func (c *blueClient) waitForVideoJob(x context.Context, id string) (*blueBlob, error) {
+	for i := 0; i < blueVideoPollMax; i++ {
+		var j blueVideoJob
+		if err := c.videoAPI(x, http.MethodGet, "app.bsky.video.getJobStatus?jobId="+url.QueryEscape(id), &j); err != nil {
+			return nil, errors.New("video job status check failed: " + err.Error())
+		}
+		switch j.State {
+		case blueVideoJobComplete:
+			if j.Blob == nil {
+				return nil, errors.New("video job completed without a blob")
+			}
+			return j.Blob, nil
+		case blueVideoJobFailed:
+			return nil, errors.New("video job failed: " + j.Error)
+		}
+		select {
+		case <-x.Done():
+			return nil, x.Err()
+		case <-time.After(blueVideoPollDelay):
+		}
+	}
+	return nil, errors.New("video job did not complete in time")
+}
+func (c *blueClient) videoAPI(x context.Context, method, path string, output interface{}) error {
+	r, _ := http.NewRequestWithContext(x, method, "https://"+blueVideoServer+"/xrpc/"+path, nil)
+	if len(c.token) > 0 {
+		r.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.videoDo(r, output)
+}
+func (c *blueClient) videoDo(r *http.Request, output interface{}) error {
+	o, err := c.poster.http.Do(r)
+	if err != nil {
+		return err
+	}
+	if o.Body == nil {
+		return nil
+	}
+	err = json.NewDecoder(o.Body).Decode(output)
+	o.Body.Close()
+	return err
+}